@@ -0,0 +1,164 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package postgresql
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/dapr/pkg/logger"
+)
+
+// defaultTestPostgresImage is the version the bulk of the suite runs against; TestPostgreSQLVersionMatrix
+// additionally exercises the versions Dapr supports in production.
+const defaultTestPostgresImage = "postgres:15-alpine"
+
+// sharedDSN is the connection string for the package-wide container started in TestMain. Every
+// test in this package reuses it via newTestStore instead of spinning up its own.
+var sharedDSN string
+
+// TestMain starts one throwaway Postgres container for the whole package, so the suite needs no
+// external infra to run in CI, and tears it down once every test has finished.
+func TestMain(m *testing.M) {
+	ctx := context.Background()
+
+	container, dsn, err := newPostgresContainer(ctx, defaultTestPostgresImage)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "failed to start postgres container: %v\n", err)
+		os.Exit(1)
+	}
+	sharedDSN = dsn
+
+	code := m.Run()
+
+	_ = container.Terminate(ctx)
+	os.Exit(code)
+}
+
+// newPostgresContainer starts a throwaway Postgres container for image, waiting for it to
+// report ready before returning its DSN.
+func newPostgresContainer(ctx context.Context, image string) (testcontainers.Container, string, error) {
+	req := testcontainers.ContainerRequest{
+		Image:        image,
+		ExposedPorts: []string{"5432/tcp"},
+		Env: map[string]string{
+			"POSTGRES_USER":     "postgres",
+			"POSTGRES_PASSWORD": "postgres",
+			"POSTGRES_DB":       "dapr_test",
+		},
+		// postgres logs this line twice on a fresh cluster (once for the bootstrap instance,
+		// once for the instance that stays up) - the same signal pg_isready polls for.
+		WaitingFor: wait.ForLog("database system is ready to accept connections").
+			WithOccurrence(2).
+			WithStartupTimeout(60 * time.Second),
+	}
+
+	container, err := testcontainers.GenericContainer(ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: req,
+		Started:          true,
+	})
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to start postgres container (%s): %w", image, err)
+	}
+
+	host, err := container.Host(ctx)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get postgres container host: %w", err)
+	}
+
+	port, err := container.MappedPort(ctx, "5432/tcp")
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get postgres container port: %w", err)
+	}
+
+	dsn := fmt.Sprintf(
+		"host=%s port=%s user=postgres password=postgres dbname=dapr_test connect_timeout=10",
+		host, port.Port(),
+	)
+	return container, dsn, nil
+}
+
+// newTestStore returns a PostgreSQL store initialized against the package's shared container,
+// closing it via t.Cleanup. Future test files should reuse this helper rather than spinning up
+// their own connection.
+func newTestStore(t testing.TB) *PostgreSQL {
+	return newTestStoreWithMetadata(t, nil)
+}
+
+// newTestStoreWithMetadata is newTestStore with additional component metadata (e.g. to select a
+// non-default serializer) merged into the connection properties.
+func newTestStoreWithMetadata(t testing.TB, extra map[string]string) *PostgreSQL {
+	t.Helper()
+
+	props := map[string]string{connectionStringKey: sharedDSN}
+	for k, v := range extra {
+		props[k] = v
+	}
+
+	pgs := NewPostgreSQLStateStore(logger.NewLogger("test"))
+	t.Cleanup(func() { pgs.Close() })
+
+	if err := pgs.Init(state.Metadata{Properties: props}); err != nil {
+		t.Fatalf("failed to init postgresql store: %v", err)
+	}
+
+	return pgs
+}
+
+// TestPostgreSQLVersionMatrix runs a minimal smoke test against each Postgres version Dapr
+// supports, each in its own throwaway container, to catch version-specific regressions that the
+// default-version suite above wouldn't see.
+func TestPostgreSQLVersionMatrix(t *testing.T) {
+	versions := []string{"11-alpine", "13-alpine", "15-alpine", "16-alpine"}
+
+	for _, version := range versions {
+		version := version
+		t.Run(version, func(t *testing.T) {
+			t.Parallel()
+
+			ctx := context.Background()
+			container, dsn, err := newPostgresContainer(ctx, "postgres:"+version)
+			if err != nil {
+				t.Fatalf("failed to start postgres:%s: %v", version, err)
+			}
+			t.Cleanup(func() { _ = container.Terminate(ctx) })
+
+			pgs := NewPostgreSQLStateStore(logger.NewLogger("test"))
+			t.Cleanup(func() { pgs.Close() })
+			if err = pgs.Init(state.Metadata{
+				Properties: map[string]string{connectionStringKey: dsn},
+			}); err != nil {
+				t.Fatalf("failed to init postgresql store against postgres:%s: %v", version, err)
+			}
+
+			key := "version-matrix-key"
+			value := `{"something": "xK3wQ9pLrZbN"}`
+
+			if err = pgs.Set(&state.SetRequest{Key: key, Value: value}); err != nil {
+				t.Fatalf("Set failed against postgres:%s: %v", version, err)
+			}
+
+			getResp, err := pgs.Get(&state.GetRequest{Key: key})
+			if err != nil {
+				t.Fatalf("Get failed against postgres:%s: %v", version, err)
+			}
+			if string(getResp.Data) != value {
+				t.Fatalf("unexpected value from postgres:%s: got %s, want %s", version, getResp.Data, value)
+			}
+
+			if err = pgs.Delete(&state.DeleteRequest{Key: key}); err != nil {
+				t.Fatalf("Delete failed against postgres:%s: %v", version, err)
+			}
+		})
+	}
+}
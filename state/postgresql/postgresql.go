@@ -0,0 +1,537 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package postgresql
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	// Blank import for the side effect of registering the pgx driver with database/sql.
+	_ "github.com/jackc/pgx/v4/stdlib"
+
+	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/dapr/pkg/logger"
+)
+
+const (
+	connectionStringKey = "connectionString"
+
+	// cleanupIntervalKey configures how often the background janitor sweeps expired rows.
+	cleanupIntervalKey              = "cleanupIntervalInSeconds"
+	defaultCleanupIntervalInSeconds = 3600
+
+	// ttlInSecondsKey is the per-request metadata key used to set a time-to-live on an item.
+	ttlInSecondsKey = "ttlInSeconds"
+
+	// serializerKey selects the store's default Serializer from the component metadata;
+	// contentTypeKey overrides it for a single request.
+	serializerKey  = "serializer"
+	contentTypeKey = "contentType"
+
+	tableName          = "state"
+	migrationTableName = "dapr_state_migrations"
+
+	errMissingConnectionString = "missing connection string"
+)
+
+// PostgreSQL is a state store implementation for PostgreSQL.
+type PostgreSQL struct {
+	logger logger.Logger
+	db     *sql.DB
+
+	cleanupInterval time.Duration
+	closeCh         chan struct{}
+	closeOnce       sync.Once
+	wg              sync.WaitGroup
+
+	notifyOnce    sync.Once
+	notifyConn    *sql.Conn
+	subscribersMu sync.Mutex
+	subscribers   []*subscriber
+
+	serializer Serializer
+}
+
+// NewPostgreSQLStateStore creates a new instance of a PostgreSQL state store.
+func NewPostgreSQLStateStore(logger logger.Logger) *PostgreSQL {
+	return &PostgreSQL{
+		logger:  logger,
+		closeCh: make(chan struct{}),
+	}
+}
+
+// Init creates the database connection, brings the schema up to date, and starts the TTL janitor.
+func (p *PostgreSQL) Init(metadata state.Metadata) error {
+	connectionString, ok := metadata.Properties[connectionStringKey]
+	if !ok || connectionString == "" {
+		return errors.New(errMissingConnectionString)
+	}
+
+	db, err := sql.Open("pgx", connectionString)
+	if err != nil {
+		return fmt.Errorf("failed to open connection to PostgreSQL: %w", err)
+	}
+	p.db = db
+
+	if err = db.Ping(); err != nil {
+		return fmt.Errorf("failed to ping PostgreSQL: %w", err)
+	}
+
+	if err = p.ensureSchema(); err != nil {
+		return err
+	}
+
+	p.cleanupInterval = defaultCleanupIntervalInSeconds * time.Second
+	if v, ok := metadata.Properties[cleanupIntervalKey]; ok && v != "" {
+		seconds, parseErr := strconv.Atoi(v)
+		if parseErr != nil {
+			return fmt.Errorf("invalid value for %s: %w", cleanupIntervalKey, parseErr)
+		}
+		p.cleanupInterval = time.Duration(seconds) * time.Second
+	}
+
+	p.serializer = jsonSerializer{}
+	if v, ok := metadata.Properties[serializerKey]; ok && v != "" {
+		serializer, ok := serializersByName[v]
+		if !ok {
+			return fmt.Errorf("unsupported value for %s: %q", serializerKey, v)
+		}
+		p.serializer = serializer
+	}
+
+	// A non-positive interval (explicitly 0, or negative) disables the janitor instead of being
+	// handed to time.NewTicker, which panics on a non-positive duration.
+	if p.cleanupInterval > 0 {
+		p.wg.Add(1)
+		go p.runJanitor()
+	}
+
+	return nil
+}
+
+// migration is a single, idempotent step in the state table's schema history.
+type migration struct {
+	version int
+	query   string
+}
+
+// migrations is applied in order; each bump to the schema adds an entry here rather than
+// mutating an earlier one, so existing deployments upgrade forward only.
+var migrations = []migration{
+	{
+		version: 1,
+		query: `CREATE TABLE IF NOT EXISTS ` + tableName + ` (
+			key        text NOT NULL PRIMARY KEY,
+			value      text NOT NULL,
+			insertdate TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT now(),
+			updatedate TIMESTAMP WITH TIME ZONE
+		)`,
+	},
+	{
+		version: 2,
+		query:   `ALTER TABLE ` + tableName + ` ADD COLUMN IF NOT EXISTS expiredate TIMESTAMP WITH TIME ZONE`,
+	},
+	{
+		// v2 -> v3: widen value from text to jsonb so Query can push filter/sort/pagination
+		// down to SQL via the @> and #>> operators instead of scanning every row.
+		version: 3,
+		query:   `ALTER TABLE ` + tableName + ` ALTER COLUMN value TYPE jsonb USING value::jsonb`,
+	},
+	{
+		// Installs the trigger Subscribe relies on to learn about writes via LISTEN/NOTIFY
+		// instead of polling.
+		version: 4,
+		query: `
+			CREATE OR REPLACE FUNCTION notify_dapr_state_change() RETURNS trigger AS $body$
+			BEGIN
+				IF TG_OP = 'DELETE' THEN
+					PERFORM pg_notify('` + notifyChannel + `', json_build_object('key', OLD.key, 'op', TG_OP)::text);
+					RETURN OLD;
+				ELSE
+					PERFORM pg_notify('` + notifyChannel + `', json_build_object('key', NEW.key, 'op', TG_OP, 'etag', NEW.xmin::text)::text);
+					RETURN NEW;
+				END IF;
+			END;
+			$body$ LANGUAGE plpgsql;
+
+			DROP TRIGGER IF EXISTS dapr_state_notify ON ` + tableName + `;
+			CREATE TRIGGER dapr_state_notify
+				AFTER INSERT OR UPDATE OR DELETE ON ` + tableName + `
+				FOR EACH ROW EXECUTE FUNCTION notify_dapr_state_change();`,
+	},
+	{
+		// Supports the pluggable Serializer: value_bytes holds non-JSON payloads (gzip,
+		// MessagePack, raw binary) and content_type records which serializer wrote a row so
+		// Get knows how to read it back. value is no longer NOT NULL, since a non-JSON row
+		// leaves it null in favor of value_bytes; the CHECK constraint keeps exactly one of
+		// the two populated.
+		version: 5,
+		query: `ALTER TABLE ` + tableName + `
+			ADD COLUMN IF NOT EXISTS value_bytes bytea,
+			ADD COLUMN IF NOT EXISTS content_type text NOT NULL DEFAULT '` + jsonContentType + `',
+			ALTER COLUMN value DROP NOT NULL,
+			ADD CONSTRAINT state_value_xor_value_bytes CHECK ((value IS NOT NULL) <> (value_bytes IS NOT NULL))`,
+	},
+}
+
+// ensureSchema brings the state table up to the latest migration version, recording progress
+// in migrationTableName so repeated Init calls (and multiple store instances) are safe.
+func (p *PostgreSQL) ensureSchema() error {
+	if _, err := p.db.Exec(`CREATE TABLE IF NOT EXISTS ` + migrationTableName + ` (version INTEGER NOT NULL)`); err != nil {
+		return fmt.Errorf("failed to create migration table: %w", err)
+	}
+
+	var current int
+	row := p.db.QueryRow(`SELECT version FROM ` + migrationTableName + ` LIMIT 1`)
+	if err := row.Scan(&current); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to read schema version: %w", err)
+		}
+		current = 0
+	}
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		if err := p.applyMigration(current, m); err != nil {
+			return err
+		}
+		current = m.version
+	}
+
+	return nil
+}
+
+func (p *PostgreSQL) applyMigration(current int, m migration) error {
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start migration transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(m.query); err != nil {
+		return fmt.Errorf("failed to apply migration %d: %w", m.version, err)
+	}
+
+	if current == 0 {
+		_, err = tx.Exec(`INSERT INTO `+migrationTableName+` (version) VALUES ($1)`, m.version)
+	} else {
+		_, err = tx.Exec(`UPDATE `+migrationTableName+` SET version = $1`, m.version)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to record migration %d: %w", m.version, err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit migration %d: %w", m.version, err)
+	}
+
+	return nil
+}
+
+// Get retrieves an item by key. An item that has expired is treated the same as a missing item.
+func (p *PostgreSQL) Get(req *state.GetRequest) (*state.GetResponse, error) {
+	var (
+		value       sql.NullString
+		valueBytes  []byte
+		contentType string
+		etag        string
+		expiredate  sql.NullTime
+	)
+
+	row := p.db.QueryRow(
+		`SELECT value, value_bytes, content_type, xmin::text AS etag, expiredate FROM `+tableName+` WHERE key = $1`,
+		req.Key,
+	)
+
+	err := row.Scan(&value, &valueBytes, &contentType, &etag, &expiredate)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &state.GetResponse{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get key %s: %w", req.Key, err)
+	}
+
+	if expiredate.Valid && !expiredate.Time.After(time.Now()) {
+		return &state.GetResponse{}, nil
+	}
+
+	raw := valueBytes
+	if value.Valid {
+		raw = []byte(value.String)
+	}
+
+	serializer, ok := serializersByContentType[contentType]
+	if !ok {
+		return nil, fmt.Errorf("key %s was stored with unknown content type %q", req.Key, contentType)
+	}
+
+	data, err := serializer.Unmarshal(raw, contentType)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unmarshal value for key %s: %w", req.Key, err)
+	}
+
+	return &state.GetResponse{
+		Data: data,
+		ETag: etag,
+	}, nil
+}
+
+// dbExecer is satisfied by both *sql.DB and *sql.Tx, letting Set/Delete run either standalone
+// or as part of the transaction Multi builds.
+type dbExecer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// Set upserts an item. When req.ETag is set the write is conditioned on the row's current xmin
+// matching it; when req.Metadata carries ttlInSeconds the row is given an absolute expiredate.
+func (p *PostgreSQL) Set(req *state.SetRequest) error {
+	return p.set(p.db, req)
+}
+
+func (p *PostgreSQL) set(e dbExecer, req *state.SetRequest) error {
+	raw, err := valueToBytes(req.Value)
+	if err != nil {
+		return err
+	}
+
+	serializer, err := p.serializerFor(req)
+	if err != nil {
+		return err
+	}
+
+	serialized, contentType, err := serializer.Marshal(raw)
+	if err != nil {
+		return fmt.Errorf("failed to marshal value for key %s: %w", req.Key, err)
+	}
+
+	// Only the default JSON serializer's output goes in the jsonb column, so that Query's
+	// jsonb operators keep working; everything else is opaque bytes in value_bytes.
+	var jsonValue, binaryValue interface{}
+	if contentType == jsonContentType {
+		jsonValue = string(serialized)
+	} else {
+		binaryValue = serialized
+	}
+
+	expireAt, err := expireAtFromMetadata(req.Metadata)
+	if err != nil {
+		return err
+	}
+
+	if req.ETag == "" {
+		_, err = e.Exec(
+			`INSERT INTO `+tableName+` (key, value, value_bytes, content_type, expiredate) VALUES ($1, $2::jsonb, $3, $4, $5)
+			 ON CONFLICT (key) DO UPDATE SET value = $2::jsonb, value_bytes = $3, content_type = $4, updatedate = now(), expiredate = $5`,
+			req.Key, jsonValue, binaryValue, contentType, expireAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to set key %s: %w", req.Key, err)
+		}
+		return nil
+	}
+
+	result, err := e.Exec(
+		`UPDATE `+tableName+` SET value = $1::jsonb, value_bytes = $2, content_type = $3, updatedate = now(), expiredate = $4
+		 WHERE key = $5 AND xmin::text = $6`,
+		jsonValue, binaryValue, contentType, expireAt, req.Key, req.ETag,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to set key %s: %w", req.Key, err)
+	}
+
+	return checkRowsAffected(result, req.Key)
+}
+
+// serializerFor resolves which Serializer writes req.Value: the request's contentType metadata
+// hint if present, otherwise the store's configured default.
+func (p *PostgreSQL) serializerFor(req *state.SetRequest) (Serializer, error) {
+	contentType, ok := req.Metadata[contentTypeKey]
+	if !ok || contentType == "" {
+		return p.serializer, nil
+	}
+
+	serializer, ok := serializersByContentType[contentType]
+	if !ok {
+		return nil, fmt.Errorf("unsupported %s %q", contentTypeKey, contentType)
+	}
+	return serializer, nil
+}
+
+// Delete removes an item. When req.ETag is set the delete is conditioned on the row's current
+// xmin matching it.
+func (p *PostgreSQL) Delete(req *state.DeleteRequest) error {
+	return p.delete(p.db, req)
+}
+
+func (p *PostgreSQL) delete(e dbExecer, req *state.DeleteRequest) error {
+	if req.ETag == "" {
+		_, err := e.Exec(`DELETE FROM `+tableName+` WHERE key = $1`, req.Key)
+		if err != nil {
+			return fmt.Errorf("failed to delete key %s: %w", req.Key, err)
+		}
+		return nil
+	}
+
+	result, err := e.Exec(`DELETE FROM `+tableName+` WHERE key = $1 AND xmin::text = $2`, req.Key, req.ETag)
+	if err != nil {
+		return fmt.Errorf("failed to delete key %s: %w", req.Key, err)
+	}
+
+	return checkRowsAffected(result, req.Key)
+}
+
+// Multi executes a heterogeneous batch of SetRequest and DeleteRequest operations atomically,
+// inside a single SERIALIZABLE transaction. Any failed operation — including an etag mismatch —
+// rolls back the whole batch.
+func (p *PostgreSQL) Multi(request *state.TransactionalStateRequest) error {
+	if len(request.Operations) == 0 {
+		return nil
+	}
+
+	tx, err := p.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to start transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err = tx.Exec(`SET TRANSACTION ISOLATION LEVEL SERIALIZABLE`); err != nil {
+		return fmt.Errorf("failed to set transaction isolation level: %w", err)
+	}
+
+	for i, op := range request.Operations {
+		if err = p.execOperation(tx, op); err != nil {
+			return fmt.Errorf("operation %d failed: %w", i, err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+
+	return nil
+}
+
+func (p *PostgreSQL) execOperation(tx *sql.Tx, op state.TransactionalStateOperation) error {
+	switch req := op.Request.(type) {
+	case state.SetRequest:
+		return p.set(tx, &req)
+	case state.DeleteRequest:
+		return p.delete(tx, &req)
+	default:
+		return fmt.Errorf("unsupported operation type %q with request %T", op.Operation, op.Request)
+	}
+}
+
+// BulkSet applies each SetRequest independently; see TransactionalStore for atomic batches.
+func (p *PostgreSQL) BulkSet(req []state.SetRequest) error {
+	for i := range req {
+		if err := p.Set(&req[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// BulkDelete applies each DeleteRequest independently; see TransactionalStore for atomic batches.
+func (p *PostgreSQL) BulkDelete(req []state.DeleteRequest) error {
+	for i := range req {
+		if err := p.Delete(&req[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close stops the janitor goroutine and closes the database connection.
+func (p *PostgreSQL) Close() error {
+	p.closeOnce.Do(func() {
+		close(p.closeCh)
+	})
+
+	// Closing closeCh above cancels runNotifyLoop's blocking wait; the dedicated LISTEN
+	// connection is then closed once that wait has returned.
+	if p.notifyConn != nil {
+		p.notifyConn.Close()
+	}
+
+	p.wg.Wait()
+
+	if p.db != nil {
+		return p.db.Close()
+	}
+	return nil
+}
+
+// runJanitor periodically deletes rows whose expiredate has passed. It exits when Close is called.
+func (p *PostgreSQL) runJanitor() {
+	defer p.wg.Done()
+
+	ticker := time.NewTicker(p.cleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if _, err := p.db.Exec(`DELETE FROM ` + tableName + ` WHERE expiredate IS NOT NULL AND expiredate <= now()`); err != nil {
+				p.logger.Errorf("postgresql state store: failed to delete expired rows: %v", err)
+			}
+		case <-p.closeCh:
+			return
+		}
+	}
+}
+
+func checkRowsAffected(result sql.Result, key string) error {
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to confirm write for key %s: %w", key, err)
+	}
+	if rows == 0 {
+		return fmt.Errorf("etag mismatch for key %s", key)
+	}
+	return nil
+}
+
+// expireAtFromMetadata computes the absolute expiry to persist from a request's ttlInSeconds
+// metadata, if present.
+func expireAtFromMetadata(metadata map[string]string) (sql.NullTime, error) {
+	ttl, ok := metadata[ttlInSecondsKey]
+	if !ok || ttl == "" {
+		return sql.NullTime{}, nil
+	}
+
+	seconds, err := strconv.Atoi(ttl)
+	if err != nil {
+		return sql.NullTime{}, fmt.Errorf("invalid %s value %q: %w", ttlInSecondsKey, ttl, err)
+	}
+
+	return sql.NullTime{Time: time.Now().Add(time.Duration(seconds) * time.Second), Valid: true}, nil
+}
+
+// valueToBytes renders a SetRequest's value as the raw bytes a Serializer marshals, assuming
+// JSON for anything that isn't already a string or []byte.
+func valueToBytes(v interface{}) ([]byte, error) {
+	switch value := v.(type) {
+	case string:
+		return []byte(value), nil
+	case []byte:
+		return value, nil
+	default:
+		b, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal value: %w", err)
+		}
+		return b, nil
+	}
+}
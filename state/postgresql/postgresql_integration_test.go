@@ -5,44 +5,25 @@
 package postgresql
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"os"
 	"testing"
+	"time"
 
 	"github.com/dapr/components-contrib/state"
+	"github.com/dapr/components-contrib/state/query"
 	"github.com/dapr/dapr/pkg/logger"
 	"github.com/google/uuid"
 	"github.com/stretchr/testify/assert"
 )
 
-const (
-	connectionStringEnvKey = "DAPR_TEST_POSTGRES_CONNSTRING" // Environment variable containing the connection string
-)
-
 func TestPostgreSQLIntegration(t *testing.T) {
-	connectionString := getConnectionString()
-	if connectionString == "" {
-		t.Skipf("PostgreSQL state integration tests skipped. To enable define the connection string using environment variable '%s' (example 'export %s=\"host=localhost user=postgres password=example port=5432 connect_timeout=10 database=dapr_test\")", connectionStringEnvKey, connectionStringEnvKey)
-	}
-
 	t.Run("Test init configurations", func(t *testing.T) {
 		testInitConfiguration(t)
 	})
 
-	metadata := state.Metadata{
-		Properties: map[string]string{connectionStringKey: connectionString},
-	}
-
-	pgs := NewPostgreSQLStateStore(logger.NewLogger("test"))
-	t.Cleanup(func() {
-		defer pgs.Close()
-	})
-
-	error := pgs.Init(metadata)
-	if error != nil {
-		t.Fatal(error)
-	}
+	pgs := newTestStore(t)
 
 	// Can set and get an item.
 	t.Run("Get Set Delete one item", func(t *testing.T) {
@@ -91,6 +72,401 @@ func TestPostgreSQLIntegration(t *testing.T) {
 		t.Parallel()
 		deleteWithInvalidEtagFails(t, pgs)
 	})
+
+	// TTL set on insert expires the item
+	t.Run("TTL set on insert expires the item", func(t *testing.T) {
+		t.Parallel()
+		ttlSetOnInsertExpiresItem(t, pgs)
+	})
+
+	// TTL can be added to an item on update
+	t.Run("TTL updated on set", func(t *testing.T) {
+		t.Parallel()
+		ttlUpdatedOnSet(t, pgs)
+	})
+
+	// Get on an expired item behaves like Get on a missing item
+	t.Run("Expired item returns nil from Get", func(t *testing.T) {
+		t.Parallel()
+		expiredItemReturnsNilFromGet(t, pgs)
+	})
+
+	// The janitor goroutine deletes expired rows in the background
+	t.Run("Janitor deletes expired rows", func(t *testing.T) {
+		janitorDeletesExpiredRows(t)
+	})
+
+	// Multi applies a heterogeneous batch of sets and deletes atomically
+	t.Run("Multi commits a batch of sets and deletes", func(t *testing.T) {
+		t.Parallel()
+		multiCommitsBatch(t, pgs)
+	})
+
+	// An etag mismatch partway through a batch rolls back the earlier operations too
+	t.Run("Multi rolls back on etag mismatch", func(t *testing.T) {
+		t.Parallel()
+		multiRollsBackOnEtagMismatch(t, pgs)
+	})
+
+	// A concurrent writer that changes a row between Get and Multi causes the batch to fail
+	t.Run("Multi detects concurrent writer conflicts", func(t *testing.T) {
+		t.Parallel()
+		multiDetectsConcurrentWriterConflict(t, pgs)
+	})
+
+	// Query translates an equality filter into a jsonb containment check
+	t.Run("Query equality filter", func(t *testing.T) {
+		t.Parallel()
+		queryEqualityFilter(t, pgs)
+	})
+
+	// Query translates a nested-path filter into a jsonb path-extraction comparison
+	t.Run("Query nested path filter", func(t *testing.T) {
+		t.Parallel()
+		queryNestedPathFilter(t, pgs)
+	})
+
+	// Query honors sort ordering over a nested path
+	t.Run("Query sort ordering", func(t *testing.T) {
+		t.Parallel()
+		querySortOrdering(t, pgs)
+	})
+
+	// Query pages through results using the token it returns
+	t.Run("Query pagination", func(t *testing.T) {
+		t.Parallel()
+		queryPagination(t, pgs)
+	})
+
+	// Subscribe streams Set/Delete events from another connection in order, with etags
+	t.Run("Subscribe receives set and delete events", func(t *testing.T) {
+		t.Parallel()
+		subscribeReceivesSetAndDeleteEvents(t, pgs)
+	})
+}
+
+// queryFixture is a small, deterministic set of JSON documents shared by the Query tests below.
+type queryFixture struct {
+	key   string
+	value string
+	city  string
+	age   int
+}
+
+// loadQueryFixture inserts a fixed set of documents, all sharing the given group so unrelated
+// rows (left over from other tests) can't be picked up by the query.
+func loadQueryFixture(t *testing.T, pgs *PostgreSQL, group string) []queryFixture {
+	fixtures := []queryFixture{
+		{city: "Seattle", age: 41},
+		{city: "Seattle", age: 29},
+		{city: "Portland", age: 35},
+	}
+
+	for i := range fixtures {
+		fixtures[i].key = uuid.New().String()
+		fixtures[i].value = fmt.Sprintf(
+			`{"group": %q, "age": %d, "address": {"city": %q}}`,
+			group, fixtures[i].age, fixtures[i].city,
+		)
+		setItem(t, pgs, fixtures[i].key, fixtures[i].value, "")
+	}
+
+	return fixtures
+}
+
+func queryEqualityFilter(t *testing.T, pgs *PostgreSQL) {
+	group := uuid.New().String()
+	fixtures := loadQueryFixture(t, pgs, group)
+
+	resp, err := pgs.Query(&query.QueryRequest{
+		Query: query.Query{
+			Filters: query.AND{Filters: []query.Filter{
+				query.EQ{Key: "group", Val: group},
+				query.EQ{Key: "age", Val: fixtures[0].age},
+			}},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Len(t, resp.Results, 1)
+	assert.Equal(t, fixtures[0].key, resp.Results[0].Key)
+}
+
+func queryNestedPathFilter(t *testing.T, pgs *PostgreSQL) {
+	group := uuid.New().String()
+	fixtures := loadQueryFixture(t, pgs, group)
+
+	resp, err := pgs.Query(&query.QueryRequest{
+		Query: query.Query{
+			Filters: query.AND{Filters: []query.Filter{
+				query.EQ{Key: "group", Val: group},
+				query.EQ{Key: "address.city", Val: "Seattle"},
+			}},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Len(t, resp.Results, 2)
+
+	keys := []string{resp.Results[0].Key, resp.Results[1].Key}
+	assert.Contains(t, keys, fixtures[0].key)
+	assert.Contains(t, keys, fixtures[1].key)
+}
+
+func querySortOrdering(t *testing.T, pgs *PostgreSQL) {
+	group := uuid.New().String()
+	fixtures := loadQueryFixture(t, pgs, group)
+
+	resp, err := pgs.Query(&query.QueryRequest{
+		Query: query.Query{
+			Filters: query.EQ{Key: "group", Val: group},
+			Sort:    []query.Sorting{{Key: "age", Order: "ASC"}},
+		},
+	})
+	assert.Nil(t, err)
+	assert.Len(t, resp.Results, 3)
+	assert.Equal(t, fixtures[1].key, resp.Results[0].Key) // age 29
+	assert.Equal(t, fixtures[2].key, resp.Results[1].Key) // age 35
+	assert.Equal(t, fixtures[0].key, resp.Results[2].Key) // age 41
+}
+
+func queryPagination(t *testing.T, pgs *PostgreSQL) {
+	group := uuid.New().String()
+	loadQueryFixture(t, pgs, group)
+
+	seen := map[string]bool{}
+	token := ""
+	for page := 0; page < 3; page++ {
+		resp, err := pgs.Query(&query.QueryRequest{
+			Query: query.Query{
+				Filters: query.EQ{Key: "group", Val: group},
+				Sort:    []query.Sorting{{Key: "age", Order: "ASC"}},
+				Page:    query.Pagination{Limit: 1, Token: token},
+			},
+		})
+		assert.Nil(t, err)
+		assert.Len(t, resp.Results, 1)
+		seen[resp.Results[0].Key] = true
+
+		token = resp.Token
+		if page < 2 {
+			assert.NotEqual(t, "", token)
+		} else {
+			// The third page exactly exhausts the fixture, so no further page remains and the
+			// token should come back empty instead of sending the caller on one more empty query.
+			assert.Equal(t, "", token)
+		}
+	}
+
+	assert.Len(t, seen, 3)
+}
+
+// subscribeReceivesSetAndDeleteEvents proves that Subscribe streams the insert/update/delete
+// notifications raised by the dapr_state_notify trigger, in order, with an etag on the insert
+// that changes on the update.
+func subscribeReceivesSetAndDeleteEvents(t *testing.T, pgs *PostgreSQL) {
+	key := uuid.New().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	events, err := pgs.Subscribe(ctx, key)
+	assert.Nil(t, err)
+
+	// Perform the writes through a second store instance so they travel over a distinct
+	// connection, the same way an unrelated Dapr sidecar's writes would.
+	otherPgs := NewPostgreSQLStateStore(logger.NewLogger("test"))
+	defer otherPgs.Close()
+	assert.Nil(t, otherPgs.Init(state.Metadata{
+		Properties: map[string]string{connectionStringKey: sharedDSN},
+	}))
+
+	setItem(t, otherPgs, key, `{"something": "oR7vWnE2sXqJ"}`, "")
+	insertEvent := waitForEvent(t, events)
+	assert.Equal(t, key, insertEvent.Key)
+	assert.Equal(t, "INSERT", insertEvent.Op)
+	assert.NotEqual(t, "", insertEvent.ETag)
+
+	setItem(t, otherPgs, key, `{"newthing": "fD4kYhL8mTzQ"}`, "")
+	updateEvent := waitForEvent(t, events)
+	assert.Equal(t, key, updateEvent.Key)
+	assert.Equal(t, "UPDATE", updateEvent.Op)
+	assert.NotEqual(t, insertEvent.ETag, updateEvent.ETag)
+
+	deleteItem(t, otherPgs, key, "")
+	deleteEvent := waitForEvent(t, events)
+	assert.Equal(t, key, deleteEvent.Key)
+	assert.Equal(t, "DELETE", deleteEvent.Op)
+}
+
+func waitForEvent(t *testing.T, events <-chan StateChangeEvent) StateChangeEvent {
+	select {
+	case evt := <-events:
+		return evt
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for a state change event")
+		return StateChangeEvent{}
+	}
+}
+
+// multiCommitsBatch proves a batch mixing Upsert and Delete operations commits as one unit.
+func multiCommitsBatch(t *testing.T, pgs *PostgreSQL) {
+	keepKey := uuid.New().String()
+	deleteKey := uuid.New().String()
+	setItem(t, pgs, deleteKey, `{"something": "r1TqY4oDkXwZ"}`, "")
+
+	err := pgs.Multi(&state.TransactionalStateRequest{
+		Operations: []state.TransactionalStateOperation{
+			{
+				Operation: state.Upsert,
+				Request:   state.SetRequest{Key: keepKey, Value: `{"something": "n8VdK2eRfHqL"}`},
+			},
+			{
+				Operation: state.Delete,
+				Request:   state.DeleteRequest{Key: deleteKey},
+			},
+		},
+	})
+	assert.Nil(t, err)
+
+	assert.True(t, storeItemExists(t, keepKey))
+	assert.False(t, storeItemExists(t, deleteKey))
+}
+
+// multiRollsBackOnEtagMismatch proves that when operation N in a batch fails its etag check,
+// operations 1..N-1 are not left applied.
+func multiRollsBackOnEtagMismatch(t *testing.T, pgs *PostgreSQL) {
+	key := uuid.New().String()
+	setItem(t, pgs, key, `{"something": "wP3jN7gLtVxQ"}`, "")
+
+	otherKey := uuid.New().String()
+
+	err := pgs.Multi(&state.TransactionalStateRequest{
+		Operations: []state.TransactionalStateOperation{
+			{
+				Operation: state.Upsert,
+				Request:   state.SetRequest{Key: otherKey, Value: `{"something": "Zb9XoW1uKdCs"}`},
+			},
+			{
+				Operation: state.Delete,
+				Request:   state.DeleteRequest{Key: key, ETag: "1234"},
+			},
+		},
+	})
+	assert.NotNil(t, err)
+
+	// The first operation must not have survived the rollback of the second.
+	assert.False(t, storeItemExists(t, otherKey))
+	assert.True(t, storeItemExists(t, key))
+}
+
+// multiDetectsConcurrentWriterConflict proves that a Multi batch built from a stale etag loses
+// to a writer that updated the row in between, just like a standalone Set would.
+func multiDetectsConcurrentWriterConflict(t *testing.T, pgs *PostgreSQL) {
+	key := uuid.New().String()
+	setItem(t, pgs, key, `{"something": "hS5mC8aPqYoN"}`, "")
+	original := getItem(t, pgs, key)
+
+	// A concurrent writer updates the row after the batch's etag was read.
+	setItem(t, pgs, key, `{"newthing": "tE6bF0rJxWnM"}`, original.ETag)
+
+	err := pgs.Multi(&state.TransactionalStateRequest{
+		Operations: []state.TransactionalStateOperation{
+			{
+				Operation: state.Upsert,
+				Request:   state.SetRequest{Key: key, ETag: original.ETag, Value: `{"newthing2": "uI1dG4sKzLoP"}`},
+			},
+		},
+	})
+	assert.NotNil(t, err)
+}
+
+// ttlSetOnInsertExpiresItem proves that an item inserted with a short TTL is gone once it elapses.
+func ttlSetOnInsertExpiresItem(t *testing.T, pgs *PostgreSQL) {
+	key := uuid.New().String()
+	value := `{"something": "7vQbM3xKzzWr"}`
+
+	setReq := &state.SetRequest{
+		Key:      key,
+		Value:    value,
+		Metadata: map[string]string{"ttlInSeconds": "1"},
+	}
+	assert.Nil(t, pgs.Set(setReq))
+	assert.True(t, storeItemExists(t, key))
+
+	getResponse := getItem(t, pgs, key)
+	assert.Equal(t, value, string(getResponse.Data))
+
+	time.Sleep(2 * time.Second)
+
+	getResponse = getItem(t, pgs, key)
+	assert.Nil(t, getResponse.Data)
+}
+
+// ttlUpdatedOnSet proves that a subsequent Set can add a TTL to an item inserted without one.
+func ttlUpdatedOnSet(t *testing.T, pgs *PostgreSQL) {
+	key := uuid.New().String()
+	value := `{"something": "bYhF9kRp2Wd1"}`
+	setItem(t, pgs, key, value, "")
+
+	newValue := `{"newthing": "mC4tL0qXsZyv"}`
+	setReq := &state.SetRequest{
+		Key:      key,
+		Value:    newValue,
+		Metadata: map[string]string{"ttlInSeconds": "1"},
+	}
+	assert.Nil(t, pgs.Set(setReq))
+
+	time.Sleep(2 * time.Second)
+
+	getResponse := getItem(t, pgs, key)
+	assert.Nil(t, getResponse.Data)
+}
+
+// expiredItemReturnsNilFromGet proves Get on an expired item behaves like Get on a missing key.
+func expiredItemReturnsNilFromGet(t *testing.T, pgs *PostgreSQL) {
+	key := uuid.New().String()
+	value := `{"something": "Q6nJw1VbTc8e"}`
+
+	setReq := &state.SetRequest{
+		Key:      key,
+		Value:    value,
+		Metadata: map[string]string{"ttlInSeconds": "1"},
+	}
+	assert.Nil(t, pgs.Set(setReq))
+	time.Sleep(2 * time.Second)
+
+	getResponse := getItem(t, pgs, key)
+	assert.Nil(t, getResponse.Data)
+
+	// The row is still physically present until the janitor (or a later write) removes it.
+	assert.True(t, storeItemExists(t, key))
+}
+
+// janitorDeletesExpiredRows proves the background janitor removes rows once they expire,
+// using its own short-lived store instance so the fast cleanup interval doesn't race other tests.
+func janitorDeletesExpiredRows(t *testing.T) {
+	pgs := NewPostgreSQLStateStore(logger.NewLogger("test"))
+	defer pgs.Close()
+
+	err := pgs.Init(state.Metadata{
+		Properties: map[string]string{
+			connectionStringKey: sharedDSN,
+			cleanupIntervalKey:  "1",
+		},
+	})
+	assert.Nil(t, err)
+
+	key := uuid.New().String()
+	setReq := &state.SetRequest{
+		Key:      key,
+		Value:    `{"something": "fL2pXyW8nQvT"}`,
+		Metadata: map[string]string{"ttlInSeconds": "1"},
+	}
+	assert.Nil(t, pgs.Set(setReq))
+	assert.True(t, storeItemExists(t, key))
+
+	time.Sleep(3 * time.Second)
+
+	assert.False(t, storeItemExists(t, key))
 }
 
 func deleteWithInvalidEtagFails(t *testing.T, pgs *PostgreSQL) {
@@ -269,7 +645,7 @@ func testInitConfiguration(t *testing.T) {
 		},
 		{
 			name:        "Valid connection string",
-			props:       map[string]string{connectionStringKey: getConnectionString()},
+			props:       map[string]string{connectionStringKey: sharedDSN},
 			expectedErr: "",
 		},
 	}
@@ -294,10 +670,6 @@ func testInitConfiguration(t *testing.T) {
 	}
 }
 
-func getConnectionString() string {
-	return os.Getenv(connectionStringEnvKey)
-}
-
 func setItem(t *testing.T, pgs *PostgreSQL, key string, value string, etag string) {
 	setReq := &state.SetRequest{
 		Key:   key,
@@ -335,7 +707,7 @@ func deleteItem(t *testing.T, pgs *PostgreSQL, key string, etag string) {
 }
 
 func storeItemExists(t *testing.T, key string) bool {
-	db, err := sql.Open("pgx", getConnectionString())
+	db, err := sql.Open("pgx", sharedDSN)
 	assert.Nil(t, err)
 	defer db.Close()
 
@@ -347,7 +719,7 @@ func storeItemExists(t *testing.T, key string) bool {
 }
 
 func getRowData(t *testing.T, key string) (returnValue string, insertdate sql.NullString, updatedate sql.NullString) {
-	db, err := sql.Open("pgx", getConnectionString())
+	db, err := sql.Open("pgx", sharedDSN)
 	assert.Nil(t, err)
 	defer db.Close()
 
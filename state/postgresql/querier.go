@@ -0,0 +1,206 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package postgresql
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/dapr/components-contrib/state/query"
+)
+
+// Query implements state.Querier. It translates Dapr's query AST into a parameterized SQL
+// statement executed against the jsonb value column, pushing filter, sort, and pagination down
+// to PostgreSQL rather than scanning every row in process.
+func (p *PostgreSQL) Query(req *query.QueryRequest) (*query.QueryResponse, error) {
+	where, args, err := translateFilter(req.Query.Filters, 1)
+	if err != nil {
+		return nil, fmt.Errorf("failed to translate query filters: %w", err)
+	}
+
+	offset := 0
+	if req.Query.Page.Token != "" {
+		if offset, err = decodePageToken(req.Query.Page.Token); err != nil {
+			return nil, err
+		}
+	}
+
+	// The @> and #>> operators below only make sense against jsonb, so queries only ever see
+	// rows written with the default JSON serializer. Expired rows are excluded the same way Get
+	// hides them, so Query and Get agree on what still exists.
+	stmt := `SELECT key, value, xmin::text FROM ` + tableName + ` WHERE content_type = '` + jsonContentType + `'` +
+		` AND (expiredate IS NULL OR expiredate > now())`
+	if where != "" {
+		stmt += ` AND ` + where
+	}
+	if orderBy, sortArgs := translateSort(req.Query.Sort, len(args)+1); orderBy != "" {
+		stmt += ` ORDER BY ` + orderBy
+		args = append(args, sortArgs...)
+	}
+
+	limit := req.Query.Page.Limit
+	if limit > 0 {
+		// Fetch one extra row so we can tell whether another page follows; otherwise a page that
+		// exactly fills limit always returns a token, and the caller pays for one more query that
+		// comes back empty.
+		args = append(args, limit+1)
+		stmt += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+	args = append(args, offset)
+	stmt += fmt.Sprintf(` OFFSET $%d`, len(args))
+
+	rows, err := p.db.Query(stmt, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to execute query: %w", err)
+	}
+	defer rows.Close()
+
+	resp := &query.QueryResponse{}
+	for rows.Next() {
+		var key, value, etag string
+		if err = rows.Scan(&key, &value, &etag); err != nil {
+			return nil, fmt.Errorf("failed to scan query result: %w", err)
+		}
+		resp.Results = append(resp.Results, query.QueryItem{Key: key, Data: []byte(value), ETag: etag})
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to iterate query results: %w", err)
+	}
+
+	if limit > 0 && len(resp.Results) > limit {
+		resp.Results = resp.Results[:limit]
+		resp.Token = encodePageToken(offset + limit)
+	}
+
+	return resp, nil
+}
+
+// translateFilter lowers a query.Filter into a parameterized WHERE clause fragment, starting
+// placeholder numbering at argOffset so callers can append fragments built elsewhere.
+func translateFilter(f query.Filter, argOffset int) (string, []interface{}, error) {
+	switch filter := f.(type) {
+	case nil:
+		return "", nil, nil
+	case query.EQ:
+		return translatePathComparison(filter.Key, filter.Val, "=", argOffset)
+	case query.IN:
+		if len(filter.Vals) == 0 {
+			return "FALSE", nil, nil
+		}
+		clauses := make([]string, 0, len(filter.Vals))
+		var args []interface{}
+		for _, v := range filter.Vals {
+			clause, clauseArgs, err := translatePathComparison(filter.Key, v, "=", argOffset+len(args))
+			if err != nil {
+				return "", nil, err
+			}
+			clauses = append(clauses, clause)
+			args = append(args, clauseArgs...)
+		}
+		return "(" + strings.Join(clauses, " OR ") + ")", args, nil
+	case query.AND:
+		return translateConjunction(filter.Filters, " AND ", argOffset)
+	case query.OR:
+		return translateConjunction(filter.Filters, " OR ", argOffset)
+	default:
+		return "", nil, fmt.Errorf("unsupported filter type %T", f)
+	}
+}
+
+func translateConjunction(filters []query.Filter, join string, argOffset int) (string, []interface{}, error) {
+	clauses := make([]string, 0, len(filters))
+	var args []interface{}
+	for _, sub := range filters {
+		clause, clauseArgs, err := translateFilter(sub, argOffset+len(args))
+		if err != nil {
+			return "", nil, err
+		}
+		clauses = append(clauses, clause)
+		args = append(args, clauseArgs...)
+	}
+	return "(" + strings.Join(clauses, join) + ")", args, nil
+}
+
+// translatePathComparison renders a filter on key as either a top-level containment check
+// (`value @> $N`) or, for a dotted path like "address.city", a path-extraction comparison
+// (`value #>> $N = $N+1`). The path segments are passed as a parameterized text array rather
+// than interpolated into the statement, since key comes from the caller-supplied query AST and
+// a segment containing `'`, `}`, or `,` would otherwise break out of the `'{...}'` literal.
+func translatePathComparison(key string, val interface{}, op string, argOffset int) (string, []interface{}, error) {
+	segments := strings.Split(key, ".")
+	if len(segments) == 1 {
+		return fmt.Sprintf(`value @> $%d`, argOffset), []interface{}{jsonbObject(segments, val)}, nil
+	}
+
+	return fmt.Sprintf(`value #>> $%d %s $%d`, argOffset, op, argOffset+1),
+		[]interface{}{segments, fmt.Sprintf("%v", val)}, nil
+}
+
+// translateSort renders Dapr's sort clauses as ORDER BY expressions over path-extracted values,
+// starting placeholder numbering at argOffset, and returns the path-segment arrays to append to
+// the caller's args (see translatePathComparison for why the path is parameterized rather than
+// interpolated). value #>> $N always extracts text, so a numeric field would otherwise sort
+// lexicographically (e.g. "10" before "9"); sort primarily by the numeric cast of that text when
+// it parses as a number, falling back to the raw text for non-numeric fields.
+func translateSort(sorts []query.Sorting, argOffset int) (string, []interface{}) {
+	if len(sorts) == 0 {
+		return "", nil
+	}
+
+	clauses := make([]string, len(sorts))
+	args := make([]interface{}, len(sorts))
+	for i, s := range sorts {
+		segments := strings.Split(s.Key, ".")
+		order := "ASC"
+		if strings.EqualFold(string(s.Order), "DESC") {
+			order = "DESC"
+		}
+		placeholder := fmt.Sprintf("$%d", argOffset+i)
+		args[i] = segments
+		extracted := fmt.Sprintf(`value #>> %s`, placeholder)
+		clauses[i] = fmt.Sprintf(
+			`(CASE WHEN %s ~ '^-?\d+(\.\d+)?$' THEN (%s)::numeric END) %s, %s %s`,
+			extracted, extracted, order, extracted, order,
+		)
+	}
+	return strings.Join(clauses, ", "), args
+}
+
+// jsonbObject builds the nested JSON literal {"a":{"b":val}} that `value @>` needs to match
+// val at the path described by segments.
+func jsonbObject(segments []string, val interface{}) string {
+	b, err := json.Marshal(val)
+	if err != nil {
+		b = []byte("null")
+	}
+	node := string(b)
+	for i := len(segments) - 1; i >= 0; i-- {
+		node = fmt.Sprintf(`{%q:%s}`, segments[i], node)
+	}
+	return node
+}
+
+// encodePageToken/decodePageToken persist the row offset for the next page as an opaque,
+// base64-encoded token so callers don't depend on its internal representation.
+func encodePageToken(offset int) string {
+	return base64.StdEncoding.EncodeToString([]byte(strconv.Itoa(offset)))
+}
+
+func decodePageToken(token string) (int, error) {
+	decoded, err := base64.StdEncoding.DecodeString(token)
+	if err != nil {
+		return 0, fmt.Errorf("invalid page token: %w", err)
+	}
+
+	offset, err := strconv.Atoi(string(decoded))
+	if err != nil {
+		return 0, fmt.Errorf("invalid page token: %w", err)
+	}
+	return offset, nil
+}
@@ -0,0 +1,127 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package postgresql
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/vmihailenco/msgpack/v5"
+)
+
+// jsonContentType is both the default serializer's content type and the sentinel that tells
+// set/Get to use the jsonb value column instead of the bytea value_bytes column.
+const jsonContentType = "application/json"
+
+// Serializer converts between the JSON bytes a SetRequest carries and the bytes persisted in
+// the database, reporting the content type recorded alongside them so Get can reverse it.
+type Serializer interface {
+	Marshal(data []byte) (serialized []byte, contentType string, err error)
+	Unmarshal(data []byte, contentType string) ([]byte, error)
+}
+
+// serializersByName maps the `serializer` component metadata value to its implementation.
+var serializersByName = map[string]Serializer{
+	"json":    jsonSerializer{},
+	"gzip":    gzipSerializer{},
+	"msgpack": msgpackSerializer{},
+	"binary":  binarySerializer{},
+}
+
+// serializersByContentType maps a stored (or per-request hinted) content type back to the
+// serializer that can read it.
+var serializersByContentType = map[string]Serializer{
+	jsonContentType:            jsonSerializer{},
+	"application/gzip":         gzipSerializer{},
+	"application/msgpack":      msgpackSerializer{},
+	"application/octet-stream": binarySerializer{},
+}
+
+// jsonSerializer is the store's original behavior: the value is already JSON, so it is passed
+// through unchanged; the jsonb column it is stored in still normalizes whitespace and key order.
+type jsonSerializer struct{}
+
+func (jsonSerializer) Marshal(data []byte) ([]byte, string, error) {
+	return data, jsonContentType, nil
+}
+
+func (jsonSerializer) Unmarshal(data []byte, _ string) ([]byte, error) {
+	return data, nil
+}
+
+// gzipSerializer compresses the JSON value, trading CPU for a smaller value_bytes column.
+type gzipSerializer struct{}
+
+func (gzipSerializer) Marshal(data []byte) ([]byte, string, error) {
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(data); err != nil {
+		return nil, "", fmt.Errorf("failed to gzip value: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, "", fmt.Errorf("failed to gzip value: %w", err)
+	}
+	return buf.Bytes(), "application/gzip", nil
+}
+
+func (gzipSerializer) Unmarshal(data []byte, _ string) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gzip value: %w", err)
+	}
+	defer r.Close()
+
+	decompressed, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read gzip value: %w", err)
+	}
+	return decompressed, nil
+}
+
+// msgpackSerializer re-encodes the JSON value as MessagePack, which is both smaller and faster
+// to parse than JSON for most payloads.
+type msgpackSerializer struct{}
+
+func (msgpackSerializer) Marshal(data []byte) ([]byte, string, error) {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return nil, "", fmt.Errorf("failed to parse value as JSON: %w", err)
+	}
+
+	b, err := msgpack.Marshal(v)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encode value as MessagePack: %w", err)
+	}
+	return b, "application/msgpack", nil
+}
+
+func (msgpackSerializer) Unmarshal(data []byte, _ string) ([]byte, error) {
+	var v interface{}
+	if err := msgpack.Unmarshal(data, &v); err != nil {
+		return nil, fmt.Errorf("failed to decode MessagePack value: %w", err)
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, fmt.Errorf("failed to re-encode MessagePack value as JSON: %w", err)
+	}
+	return b, nil
+}
+
+// binarySerializer stores the payload exactly as given, with no JSON assumption, for callers
+// that want PostgreSQL to hold an opaque blob.
+type binarySerializer struct{}
+
+func (binarySerializer) Marshal(data []byte) ([]byte, string, error) {
+	return data, "application/octet-stream", nil
+}
+
+func (binarySerializer) Unmarshal(data []byte, _ string) ([]byte, error) {
+	return data, nil
+}
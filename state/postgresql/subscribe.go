@@ -0,0 +1,159 @@
+// ------------------------------------------------------------
+// Copyright (c) Microsoft Corporation.
+// Licensed under the MIT License.
+// ------------------------------------------------------------
+
+package postgresql
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/jackc/pgx/v4/stdlib"
+)
+
+// notifyChannel is the Postgres NOTIFY channel the dapr_state_notify trigger publishes to.
+const notifyChannel = "dapr_state_changes"
+
+// StateChangeEvent describes a single insert, update, or delete observed via LISTEN/NOTIFY.
+type StateChangeEvent struct {
+	Key  string
+	Op   string
+	ETag string
+}
+
+// subscriber is one caller's registration; keyPattern is matched with filepath.Match semantics.
+type subscriber struct {
+	keyPattern string
+	ch         chan StateChangeEvent
+}
+
+// Subscribe streams insert/update/delete events for keys matching keyPattern (a filepath.Match
+// glob, e.g. "order-*") until ctx is canceled, enabling cache invalidation and pub-sub-over-state
+// scenarios without polling. The first call lazily starts the shared LISTEN connection and
+// multiplexing goroutine; later calls reuse it.
+func (p *PostgreSQL) Subscribe(ctx context.Context, keyPattern string) (<-chan StateChangeEvent, error) {
+	var startErr error
+	p.notifyOnce.Do(func() {
+		startErr = p.startNotifyLoop()
+	})
+	if startErr != nil {
+		return nil, startErr
+	}
+
+	sub := &subscriber{keyPattern: keyPattern, ch: make(chan StateChangeEvent, 16)}
+
+	p.subscribersMu.Lock()
+	p.subscribers = append(p.subscribers, sub)
+	p.subscribersMu.Unlock()
+
+	go func() {
+		<-ctx.Done()
+		p.unsubscribe(sub)
+	}()
+
+	return sub.ch, nil
+}
+
+func (p *PostgreSQL) unsubscribe(sub *subscriber) {
+	p.subscribersMu.Lock()
+	defer p.subscribersMu.Unlock()
+
+	for i, s := range p.subscribers {
+		if s == sub {
+			p.subscribers = append(p.subscribers[:i], p.subscribers[i+1:]...)
+			close(sub.ch)
+			return
+		}
+	}
+}
+
+// startNotifyLoop acquires a dedicated connection, issues LISTEN on it, and starts the
+// goroutine that multiplexes incoming notifications out to every matching subscriber.
+func (p *PostgreSQL) startNotifyLoop() error {
+	conn, err := p.db.Conn(context.Background())
+	if err != nil {
+		return fmt.Errorf("failed to acquire connection for LISTEN: %w", err)
+	}
+
+	if _, err = conn.ExecContext(context.Background(), `LISTEN `+notifyChannel); err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to LISTEN on %s: %w", notifyChannel, err)
+	}
+
+	p.notifyConn = conn
+
+	p.wg.Add(1)
+	go p.runNotifyLoop(conn)
+
+	return nil
+}
+
+// runNotifyLoop blocks waiting for notifications on the dedicated LISTEN connection and
+// dispatches each one to matching subscribers. It exits once Close closes closeCh, which
+// cancels the wait.
+func (p *PostgreSQL) runNotifyLoop(conn *sql.Conn) {
+	defer p.wg.Done()
+	defer conn.Close()
+
+	// sql.Conn.Close blocks until a running Raw callback returns, so the wait is canceled through
+	// its context when closeCh is closed.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-p.closeCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	for {
+		var notification *pgx.Notification
+		err := conn.Raw(func(driverConn interface{}) error {
+			var waitErr error
+			notification, waitErr = driverConn.(*stdlib.Conn).Conn().WaitForNotification(ctx)
+			return waitErr
+		})
+		if err != nil {
+			select {
+			case <-p.closeCh:
+			default:
+				p.logger.Errorf("postgresql state store: error waiting for notification: %v", err)
+			}
+			return
+		}
+
+		p.dispatch(notification.Payload)
+	}
+}
+
+func (p *PostgreSQL) dispatch(payload string) {
+	var raw struct {
+		Key  string `json:"key"`
+		Op   string `json:"op"`
+		ETag string `json:"etag"`
+	}
+	if err := json.Unmarshal([]byte(payload), &raw); err != nil {
+		p.logger.Errorf("postgresql state store: failed to unmarshal notification payload: %v", err)
+		return
+	}
+	evt := StateChangeEvent{Key: raw.Key, Op: raw.Op, ETag: raw.ETag}
+
+	p.subscribersMu.Lock()
+	defer p.subscribersMu.Unlock()
+
+	for _, sub := range p.subscribers {
+		if matched, _ := filepath.Match(sub.keyPattern, evt.Key); matched {
+			select {
+			case sub.ch <- evt:
+			default:
+				p.logger.Warnf("postgresql state store: dropping notification for slow subscriber on %s", sub.keyPattern)
+			}
+		}
+	}
+}